@@ -0,0 +1,274 @@
+package gorose
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cacher : 读缓存接口, Set 额外接收 tags 以便 Invalidate 能按标签批量失效相关的键
+type Cacher interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, val []byte, ttl time.Duration, tags ...string)
+	Invalidate(tags ...string)
+}
+
+// cacheEntry 是 MemoryCacher 里的一条记录
+type cacheEntry struct {
+	val       []byte
+	expiresAt time.Time
+}
+
+// MemoryCacher : 默认的进程内缓存实现, 按 tag 维护一组 bucket(tag -> 归属的 key 集合),
+// Invalidate 时直接清空对应 bucket 涉及的所有 key
+type MemoryCacher struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	buckets map[string]map[string]struct{}
+}
+
+// NewMemoryCacher : 初始化 MemoryCacher
+func NewMemoryCacher() *MemoryCacher {
+	return &MemoryCacher{
+		entries: make(map[string]cacheEntry),
+		buckets: make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *MemoryCacher) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.val, true
+}
+
+func (c *MemoryCacher) Set(key string, val []byte, ttl time.Duration, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	c.entries[key] = cacheEntry{val: val, expiresAt: expiresAt}
+
+	for _, tag := range tags {
+		bucket, ok := c.buckets[tag]
+		if !ok {
+			bucket = make(map[string]struct{})
+			c.buckets[tag] = bucket
+		}
+		bucket[key] = struct{}{}
+	}
+}
+
+func (c *MemoryCacher) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.buckets[tag] {
+			delete(c.entries, key)
+		}
+		delete(c.buckets, tag)
+	}
+}
+
+// RedisClient 是 RedisCacher 依赖的最小操作集, 业务方可以用 go-redis 或其他客户端适配实现,
+// 避免 gorose 核心包直接依赖某一个具体的 redis 驱动
+type RedisClient interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, val string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	SAdd(ctx context.Context, key string, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+}
+
+// RedisCacher : 基于 Redis 的 Cacher 实现, tag 用一个 Redis Set 维护其下的所有 key
+type RedisCacher struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisCacher : prefix 用于和同一个 Redis 实例上的其他数据隔离
+func NewRedisCacher(client RedisClient, prefix string) *RedisCacher {
+	return &RedisCacher{client: client, prefix: prefix}
+}
+
+func (c *RedisCacher) Get(key string) ([]byte, bool) {
+	val, err := c.client.Get(context.Background(), c.prefix+key)
+	if err != nil {
+		return nil, false
+	}
+	return []byte(val), true
+}
+
+func (c *RedisCacher) Set(key string, val []byte, ttl time.Duration, tags ...string) {
+	ctx := context.Background()
+	fullKey := c.prefix + key
+	if err := c.client.Set(ctx, fullKey, string(val), ttl); err != nil {
+		return
+	}
+	for _, tag := range tags {
+		_ = c.client.SAdd(ctx, c.tagKey(tag), fullKey)
+	}
+}
+
+func (c *RedisCacher) Invalidate(tags ...string) {
+	ctx := context.Background()
+	for _, tag := range tags {
+		tagKey := c.tagKey(tag)
+		members, err := c.client.SMembers(ctx, tagKey)
+		if err != nil {
+			continue
+		}
+		if len(members) > 0 {
+			_ = c.client.Del(ctx, members...)
+		}
+		_ = c.client.Del(ctx, tagKey)
+	}
+}
+
+func (c *RedisCacher) tagKey(tag string) string {
+	return c.prefix + "tag:" + tag
+}
+
+func init() {
+	// CachedQuery.Query 用 gob 序列化 s.BindOrigin 写入缓存, 而 encoding/gob 要求 interface{}
+	// 背后的具体类型必须先注册。OBJECT_MAP 绑定模式下 s.BindOrigin 是 map[string]interface{},
+	// 列值常见的具体类型(尤其是 time.Time)如果不注册, Encode 会直接报错导致这个绑定模式下
+	// 缓存永远写不进去
+	gob.Register(time.Time{})
+	gob.Register([]byte{})
+	gob.Register(sql.NullString{})
+	gob.Register(sql.NullInt64{})
+	gob.Register(sql.NullFloat64{})
+	gob.Register(sql.NullBool{})
+	gob.Register(sql.NullTime{})
+}
+
+// cacheKey : sha1(sql + args), args 用 %v 拼接以覆盖基础类型和切片
+func cacheKey(sqlstring string, args []interface{}) string {
+	h := sha1.New()
+	h.Write([]byte(sqlstring))
+	for _, arg := range args {
+		fmt.Fprintf(h, "|%v", arg)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CachedQuery : Session.Cache(ttl, tags...) 返回的入口, Query 命中缓存时不会访问从库
+type CachedQuery struct {
+	s    *Session
+	ttl  time.Duration
+	tags []string
+}
+
+// Cache : 返回一个带缓存的查询入口, ttl<=0 表示永不过期, tags 用于之后按表名等维度批量失效
+func (s *Session) Cache(ttl time.Duration, tags ...string) *CachedQuery {
+	return &CachedQuery{s: s, ttl: ttl, tags: tags}
+}
+
+// SetCacher : 注册 Cacher 实现, 未注册时 CachedQuery.Query 退化为直接查询
+func (s *Session) SetCacher(cacher Cacher) ISession {
+	s.cacher = cacher
+	return s
+}
+
+// Query : 查询前先探测缓存, 命中则跳过从库直接反序列化进调用方通过 Bind/Table 绑定的目标
+func (cq *CachedQuery) Query(sqlstring string, args ...interface{}) error {
+	s := cq.s
+	if s.cacher == nil || s.BindType == OBJECT_STRING {
+		return s.Query(sqlstring, args...)
+	}
+
+	key := cacheKey(sqlstring, args)
+	if raw, ok := s.cacher.Get(key); ok {
+		dec := gob.NewDecoder(bytes.NewReader(raw))
+		origin := reflect.ValueOf(s.BindOrigin)
+		if origin.Kind() == reflect.Map {
+			// OBJECT_MAP 绑定下 s.BindOrigin 本身就是调用方传入的 map[string]interface{}, 不是
+			// 指向它的指针(map 已经是引用类型), 直接 Decode(&s.BindOrigin) 会让 gob 把这个字段
+			// 换成一个新分配的 map, 调用方手里那个原始 map 不会跟着变。所以这里解到一个临时 map
+			// 上, 再通过 SetMapIndex 写回调用方原来那个 map 的底层数据
+			var fresh map[string]interface{}
+			if err := dec.Decode(&fresh); err != nil {
+				return err
+			}
+			for k, v := range fresh {
+				origin.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+			}
+			return nil
+		}
+		return dec.Decode(s.BindOrigin)
+	}
+
+	if err := s.Query(sqlstring, args...); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.BindOrigin); err != nil {
+		// 缓存写入失败不应该影响本次查询已经拿到的结果, 但不能被默默吞掉 —— 否则某个绑定类型
+		// 一直没法被正确地 gob 编码时(例如漏注册的类型), 缓存会在不报错、不留痕迹的情况下
+		// 对这个查询永远不生效
+		if s.logger != nil {
+			file, line := callerInfo()
+			s.logger.Log(LogEntry{
+				SQL:    sqlstring,
+				Args:   args,
+				Driver: s.slaveDriver,
+				Err:    fmt.Errorf("cache encode failed: %w", err),
+				File:   file,
+				Line:   line,
+			})
+		}
+		return nil
+	}
+	s.cacher.Set(key, buf.Bytes(), cq.ttl, cq.tags...)
+	return nil
+}
+
+var (
+	reInsertInto = regexp.MustCompile(`(?i)insert\s+into\s+` + "`" + `?([a-zA-Z0-9_]+)`)
+	reUpdate     = regexp.MustCompile(`(?i)update\s+` + "`" + `?([a-zA-Z0-9_]+)`)
+	reDeleteFrom = regexp.MustCompile(`(?i)delete\s+from\s+` + "`" + `?([a-zA-Z0-9_]+)`)
+)
+
+// extractTableName 从 insert/update/delete 语句里取出表名, 取不到时返回空字符串
+func extractTableName(operType, sqlstring string) string {
+	var re *regexp.Regexp
+	switch operType {
+	case "insert":
+		re = reInsertInto
+	case "update":
+		re = reUpdate
+	case "delete":
+		re = reDeleteFrom
+	default:
+		return ""
+	}
+
+	m := re.FindStringSubmatch(sqlstring)
+	if len(m) < 2 {
+		return ""
+	}
+	return strings.Trim(m[1], "`")
+}
@@ -0,0 +1,72 @@
+package gorose
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+)
+
+// TestGobRegister_MapRowWithTimeRoundTrips : OBJECT_MAP 绑定模式下 CachedQuery.Query 缓存写入
+// 就是对 s.BindOrigin(此时是 map[string]interface{})做这样一次 gob 编解码。列值里带 time.Time
+// 是最常见的触发场景(例如 created_at), 在 init() 里补上 gob.Register 之前这里会直接报
+// "gob: type not registered for interface: time.Time"
+func TestGobRegister_MapRowWithTimeRoundTrips(t *testing.T) {
+	row := map[string]interface{}{
+		"id":         1,
+		"name":       "gorose",
+		"created_at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&row); err != nil {
+		t.Fatalf("expected map[string]interface{} containing time.Time to gob-encode, got error: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("expected gob-decode to succeed, got error: %v", err)
+	}
+
+	got, ok := decoded["created_at"].(time.Time)
+	if !ok {
+		t.Fatalf("expected created_at to decode back to time.Time, got %T", decoded["created_at"])
+	}
+	if !got.Equal(row["created_at"].(time.Time)) {
+		t.Fatalf("got %v, want %v", got, row["created_at"])
+	}
+}
+
+// TestCachedQuery_Query_MapBindCacheHit : OBJECT_MAP 绑定下 s.BindOrigin 是调用方传入的裸
+// map[string]interface{}, 不是指向它的指针。这里跳过真正访问从库, 直接往 cacher 里塞一条
+// 等价于"上一次查询已经写入缓存"的记录, 驱动 CachedQuery.Query 走缓存命中分支, 确认
+// gob.Decode 不会因为 BindOrigin 不是指针而报 "gob: attempt to decode into a non-pointer"
+func TestCachedQuery_Query_MapBindCacheHit(t *testing.T) {
+	const sqlstring = "SELECT * FROM users WHERE id = ?"
+	args := []interface{}{1}
+
+	cached := map[string]interface{}{
+		"id":   1,
+		"name": "gorose",
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&cached); err != nil {
+		t.Fatalf("failed to seed the cache entry: %v", err)
+	}
+
+	cacher := NewMemoryCacher()
+	cacher.Set(cacheKey(sqlstring, args), buf.Bytes(), 0)
+
+	bound := map[string]interface{}{}
+	s := &Session{Binder: new(Binder), cacher: cacher}
+	s.BindOrigin = bound
+	s.BindType = OBJECT_MAP
+
+	if err := s.Cache(0).Query(sqlstring, args[0]); err != nil {
+		t.Fatalf("expected cache hit to decode cleanly, got error: %v", err)
+	}
+
+	if bound["name"] != "gorose" {
+		t.Fatalf("expected bound map to be populated from the cache, got %v", bound)
+	}
+}
@@ -0,0 +1,96 @@
+package gorose
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrNoInConditions : 传入的切片参数为空时返回, 避免生成 "IN ()" 这种非法 SQL
+var ErrNoInConditions = errors.New("gorose: empty slice argument for IN clause")
+
+// expandInArgs 将 args 中的切片参数(排除 []byte)展开为多个占位符, 使 `WHERE id IN (?)` 配合 []int{1,2,3}
+// 这样的调用无需业务代码手动拼接 SQL。driver 为 postgres 时占位符改写为 $1,$2,... 以匹配其协议。
+func expandInArgs(driver, sqlstring string, args []interface{}) (string, []interface{}, error) {
+	if len(args) == 0 || !strings.ContainsRune(sqlstring, '?') {
+		return sqlstring, args, nil
+	}
+
+	var sb strings.Builder
+	out := make([]interface{}, 0, len(args))
+	argIdx := 0
+	placeholderN := 0
+	var quote byte // 当前所在的引号字符(' " `), 0 表示不在引号内
+
+	for i := 0; i < len(sqlstring); i++ {
+		c := sqlstring[i]
+
+		if quote != 0 {
+			sb.WriteByte(c)
+			if c == quote {
+				// 引号内用双写表示转义, 如 'it''s' 或 "a""b", 遇到则仍停留在引号内
+				if i+1 < len(sqlstring) && sqlstring[i+1] == quote {
+					i++
+					sb.WriteByte(sqlstring[i])
+					continue
+				}
+				quote = 0
+			}
+			continue
+		}
+
+		if c == '\'' || c == '"' || c == '`' {
+			quote = c
+			sb.WriteByte(c)
+			continue
+		}
+
+		if c != '?' || argIdx >= len(args) {
+			sb.WriteByte(c)
+			continue
+		}
+
+		arg := args[argIdx]
+		argIdx++
+
+		if n, slice, ok := sliceArg(arg); ok {
+			if n == 0 {
+				return "", nil, ErrNoInConditions
+			}
+			placeholders := make([]string, n)
+			for j := 0; j < n; j++ {
+				placeholderN++
+				placeholders[j] = placeholder(driver, placeholderN)
+				out = append(out, slice.Index(j).Interface())
+			}
+			sb.WriteString(strings.Join(placeholders, ","))
+			continue
+		}
+
+		placeholderN++
+		sb.WriteString(placeholder(driver, placeholderN))
+		out = append(out, arg)
+	}
+
+	return sb.String(), out, nil
+}
+
+// sliceArg 判断 arg 是否需要按 IN 条件展开: 必须是切片且不是 []byte(否则会破坏 blob/字符串参数)
+func sliceArg(arg interface{}) (n int, v reflect.Value, ok bool) {
+	if arg == nil {
+		return 0, reflect.Value{}, false
+	}
+	v = reflect.ValueOf(arg)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return 0, reflect.Value{}, false
+	}
+	return v.Len(), v, true
+}
+
+func placeholder(driver string, n int) string {
+	if driver == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
@@ -0,0 +1,55 @@
+package gorose
+
+import "testing"
+
+func TestExpandInArgs_IgnoresQuestionMarkInsideStringLiteral(t *testing.T) {
+	sqlstring, args, err := expandInArgs("postgres", "SELECT * FROM t WHERE note = 'a?b' AND id IN (?)", []interface{}{[]int{1, 2, 3}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "SELECT * FROM t WHERE note = 'a?b' AND id IN ($1,$2,$3)"
+	if sqlstring != want {
+		t.Fatalf("got sql %q, want %q", sqlstring, want)
+	}
+	if len(args) != 3 {
+		t.Fatalf("got %d args, want 3", len(args))
+	}
+}
+
+func TestExpandInArgs_IgnoresQuestionMarkInQuotedIdentifier(t *testing.T) {
+	sqlstring, args, err := expandInArgs("mysql", "SELECT * FROM `weird?table` WHERE id IN (?)", []interface{}{[]int{1, 2}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "SELECT * FROM `weird?table` WHERE id IN (?,?)"
+	if sqlstring != want {
+		t.Fatalf("got sql %q, want %q", sqlstring, want)
+	}
+	if len(args) != 2 {
+		t.Fatalf("got %d args, want 2", len(args))
+	}
+}
+
+func TestExpandInArgs_HandlesEscapedQuoteInsideLiteral(t *testing.T) {
+	sqlstring, args, err := expandInArgs("mysql", "SELECT * FROM t WHERE note = 'it''s ?' AND id = ?", []interface{}{42})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "SELECT * FROM t WHERE note = 'it''s ?' AND id = ?"
+	if sqlstring != want {
+		t.Fatalf("got sql %q, want %q", sqlstring, want)
+	}
+	if len(args) != 1 || args[0] != 42 {
+		t.Fatalf("got args %v, want [42]", args)
+	}
+}
+
+func TestExpandInArgs_EmptySliceReturnsErrNoInConditions(t *testing.T) {
+	_, _, err := expandInArgs("mysql", "SELECT * FROM t WHERE id IN (?)", []interface{}{[]int{}})
+	if err != ErrNoInConditions {
+		t.Fatalf("got err %v, want ErrNoInConditions", err)
+	}
+}
@@ -0,0 +1,44 @@
+package gorose
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ISession : Session 对外暴露的完整接口, NewSession 返回该接口而不是 *Session,
+// 方便业务层和测试用 mock 替换真实实现。新增到 *Session 上的方法都要同步加到这里,
+// 否则只持有 ISession 的调用方(包括 Transaction/TransactionContext 传给闭包的 ses)
+// 永远用不上
+type ISession interface {
+	Close()
+	GetDriver() string
+
+	Table(tab interface{}) ISession
+	Bind(tab interface{}) ISession
+
+	Begin() error
+	BeginTx(ctx context.Context, opts *sql.TxOptions) error
+	Rollback() error
+	Commit() error
+	Savepoint(name string) error
+	RollbackTo(name string) error
+	Release(name string) error
+	Transaction(closers ...func(ses ISession) error) error
+	TransactionContext(ctx context.Context, closers ...func(ctx context.Context, ses ISession) error) error
+
+	Query(sqlstring string, args ...interface{}) error
+	QueryContext(ctx context.Context, sqlstring string, args ...interface{}) error
+	Execute(sqlstring string, args ...interface{}) (rowsAffected int64, err error)
+	ExecuteContext(ctx context.Context, sqlstring string, args ...interface{}) (rowsAffected int64, err error)
+
+	LastInsertId() int64
+	LastInsertSql() string
+
+	EnablePrepareCache(size int) ISession
+	SetLogger(logger ILogger) ISession
+	SetSlowThreshold(d time.Duration) ISession
+
+	Cache(ttl time.Duration, tags ...string) *CachedQuery
+	SetCacher(cacher Cacher) ISession
+}
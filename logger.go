@@ -0,0 +1,139 @@
+package gorose
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// LogEntry : 一次 SQL 执行的完整上下文, 供 ILogger 消费
+type LogEntry struct {
+	SQL          string        // 格式化后的 SQL
+	Args         []interface{} // 绑定参数
+	Driver       string        // 当前使用的驱动, 如 mysql/postgres
+	Duration     time.Duration // 本次执行耗时
+	RowsAffected int64         // Execute 受影响的行数, 执行失败时为 0; Query 不统计返回行数, 恒为 0
+	Err          error         // 执行过程中产生的错误, 无错误时为 nil
+	Slow         bool          // 是否超过 SlowThreshold
+	File         string        // 调用方文件
+	Line         int           // 调用方行号
+}
+
+// ILogger : 可插拔的 SQL 日志接口, 通过 Session.SetLogger 注册
+type ILogger interface {
+	Log(entry LogEntry)
+}
+
+// callerInfo 定位调用方的文件名和行号。必须在 Query/Execute/QueryContext/ExecuteContext 这些
+// 面向业务的入口函数里直接调用(而不是在它们共同委托的内部实现里调用), 这样 skip=2 永远指向
+// "调用这些入口函数的业务代码", 不会因为业务方选择 Query 还是 QueryContext 而深度不同。
+func callerInfo() (file string, line int) {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return "???", 0
+	}
+	return file, line
+}
+
+// StdoutLogger : 默认实现, 将日志打印到标准输出, 慢查询会带上 [SLOW] 标记
+type StdoutLogger struct {
+	logger *log.Logger
+}
+
+// NewStdoutLogger : 初始化 StdoutLogger
+func NewStdoutLogger() *StdoutLogger {
+	return &StdoutLogger{logger: log.New(os.Stdout, "[gorose] ", log.LstdFlags)}
+}
+
+func (l *StdoutLogger) Log(entry LogEntry) {
+	l.logger.Println(formatLogEntry(entry))
+}
+
+// FileLogger : 将日志写入文件, 按 MaxBytes 大小滚动, 保留最近 MaxBackups 个历史文件。
+// 一个 ILogger 实例通常通过 SetLogger 被多个 Session/goroutine 共享, 所以 Log/rotate
+// 必须用 mu 互斥, 否则并发写会竞争 written, 甚至让某个 goroutine 在 rotate 重新打开
+// 文件期间拿到一个已经被关闭的 *os.File
+type FileLogger struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	written    int64
+}
+
+// NewFileLogger : path 为日志文件路径, maxBytes 触发滚动的文件大小, maxBackups 保留的历史文件数
+func NewFileLogger(path string, maxBytes int64, maxBackups int) (*FileLogger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileLogger{path: path, maxBytes: maxBytes, maxBackups: maxBackups, file: f, written: stat.Size()}, nil
+}
+
+func (l *FileLogger) Log(entry LogEntry) {
+	line := formatLogEntry(entry) + "\n"
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxBytes > 0 && l.written+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return
+		}
+	}
+	n, err := l.file.WriteString(line)
+	if err == nil {
+		l.written += int64(n)
+	}
+}
+
+// rotate 要求调用方已经持有 l.mu
+func (l *FileLogger) rotate() error {
+	l.file.Close()
+
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		oldPath := fmt.Sprintf("%s.%d", l.path, i)
+		newPath := fmt.Sprintf("%s.%d", l.path, i+1)
+		if _, err := os.Stat(oldPath); err == nil {
+			os.Rename(oldPath, newPath)
+		}
+	}
+	if l.maxBackups > 0 {
+		os.Rename(l.path, l.path+".1")
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	l.file = f
+	l.written = 0
+	return nil
+}
+
+// Close : 关闭底层日志文件
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+func formatLogEntry(entry LogEntry) string {
+	prefix := ""
+	if entry.Slow {
+		prefix = "[SLOW] "
+	}
+	if entry.Err != nil {
+		return fmt.Sprintf("%s[%s] %s | args=%v | %s | err=%v", prefix, entry.Driver, entry.SQL, entry.Args, entry.Duration, entry.Err)
+	}
+	return fmt.Sprintf("%s[%s] %s | args=%v | %s | rows=%d", prefix, entry.Driver, entry.SQL, entry.Args, entry.Duration, entry.RowsAffected)
+}
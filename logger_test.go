@@ -0,0 +1,39 @@
+package gorose
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// entryPoint 在结构上模拟 Query/QueryContext 等入口: 直接在自己的栈帧里调用 callerInfo(),
+// 而不是在它们共同委托的内部实现里调用。
+func entryPoint() (string, int) {
+	return callerInfo()
+}
+
+func TestCallerInfo_ReportsCallerOfEntryPoint(t *testing.T) {
+	_, _, here, _ := runtime.Caller(0)
+	file, line := entryPoint() // <- 这一行应该被精确报告
+
+	if !strings.HasSuffix(file, "logger_test.go") {
+		t.Fatalf("got file %q, want logger_test.go", file)
+	}
+	if line != here+1 {
+		t.Fatalf("got line %d, want %d (the line that calls entryPoint)", line, here+1)
+	}
+}
+
+func TestCallerInfo_DistinctCallSitesReportDistinctLines(t *testing.T) {
+	// 同一个入口从两个不同的调用点调用: 只要 callerInfo() 是在入口函数自身调用的, 报告的行号应该
+	// 分别指向下面这两行, 而不是入口函数内部调用 callerInfo() 的那一行。
+	file1, line1 := entryPoint()
+	file2, line2 := entryPoint()
+
+	if line1 == line2 {
+		t.Fatalf("expected distinct call sites to report distinct lines, got %d twice", line1)
+	}
+	if !strings.HasSuffix(file1, "logger_test.go") || !strings.HasSuffix(file2, "logger_test.go") {
+		t.Fatalf("got files %q and %q, want logger_test.go", file1, file2)
+	}
+}
@@ -0,0 +1,223 @@
+package gorose
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// mysqlTimeLayouts 按常见程度排列, 用于从字符串/[]byte 解析 time.Time 字段
+var mysqlTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+// fieldPlanEntry 描述结构体中一个可扫描字段: index 供 reflect.Value.FieldByIndex 定位,
+// 支持穿透匿名内嵌结构体; isJSON 标记该字段需要通过 `gorose:"json"` 标签反序列化
+type fieldPlanEntry struct {
+	index  []int
+	isJSON bool
+}
+
+// fieldPlanCache 以 reflect.Type 为 key 缓存字段扫描计划, 避免每次扫描都重新遍历结构体
+var fieldPlanCache sync.Map // map[reflect.Type][]fieldPlanEntry
+
+func getFieldPlan(t reflect.Type) []fieldPlanEntry {
+	if cached, ok := fieldPlanCache.Load(t); ok {
+		return cached.([]fieldPlanEntry)
+	}
+	plan := buildFieldPlan(t, nil)
+	fieldPlanCache.Store(t, plan)
+	return plan
+}
+
+// buildFieldPlan 按字段声明顺序展开, 匿名内嵌结构体递归展开为同一级字段, 未导出字段被跳过
+func buildFieldPlan(t reflect.Type, prefix []int) (plan []fieldPlanEntry) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		index := append(append([]int{}, prefix...), i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			plan = append(plan, buildFieldPlan(f.Type, index)...)
+			continue
+		}
+
+		plan = append(plan, fieldPlanEntry{
+			index:  index,
+			isJSON: f.Tag.Get("gorose") == "json",
+		})
+	}
+	return
+}
+
+// scanStructRow 按 plan 顺序将当前行扫描进 structPtr(一个指向 struct 的指针), 逐列处理 NULL/时间/JSON
+func scanStructRow(rows *sql.Rows, structPtr interface{}) error {
+	t := reflect.TypeOf(structPtr).Elem()
+	plan := getFieldPlan(t)
+
+	raws := make([]interface{}, len(plan))
+	scanArgs := make([]interface{}, len(plan))
+	for i := range raws {
+		scanArgs[i] = &raws[i]
+	}
+
+	if err := rows.Scan(scanArgs...); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(structPtr).Elem()
+	for i, entry := range plan {
+		raw := raws[i]
+		if raw == nil {
+			continue
+		}
+
+		fv := v.FieldByIndex(entry.index)
+		if err := assignField(fv, entry, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignField 把一个 NULL-安全的原始驱动值写入目标字段, 按需做 JSON 反序列化或时间解析
+func assignField(fv reflect.Value, entry fieldPlanEntry, raw interface{}) error {
+	if entry.isJSON {
+		return json.Unmarshal(toBytes(raw), fv.Addr().Interface())
+	}
+
+	if fv.Type() == timeType {
+		t, err := parseTimeValue(raw)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(toStr(raw))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt64(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt64(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat64(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		fv.SetBool(toBool(raw))
+	default:
+		rv := reflect.ValueOf(raw)
+		if rv.Type().AssignableTo(fv.Type()) {
+			fv.Set(rv)
+		}
+	}
+	return nil
+}
+
+func parseTimeValue(raw interface{}) (time.Time, error) {
+	if t, ok := raw.(time.Time); ok {
+		return t, nil
+	}
+
+	s := toStr(raw)
+	var lastErr error
+	for _, layout := range mysqlTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		} else {
+			lastErr = err
+		}
+	}
+	return time.Time{}, fmt.Errorf("gorose: unable to parse time value %q: %w", s, lastErr)
+}
+
+func toBytes(raw interface{}) []byte {
+	switch v := raw.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+func toStr(raw interface{}) string {
+	switch v := raw.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt64(raw interface{}) (int64, error) {
+	switch v := raw.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("gorose: cannot convert %T to int64", raw)
+	}
+}
+
+func toFloat64(raw interface{}) (float64, error) {
+	switch v := raw.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("gorose: cannot convert %T to float64", raw)
+	}
+}
+
+func toBool(raw interface{}) bool {
+	switch v := raw.(type) {
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case []byte:
+		return string(v) == "1" || string(v) == "true"
+	case string:
+		return v == "1" || v == "true"
+	default:
+		return false
+	}
+}
@@ -1,24 +1,86 @@
 package gorose
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type Session struct {
 	IEngin
 	*Binder
-	slaveDB      *sql.DB
-	masterDB     *sql.DB
-	tx           *sql.Tx
-	masterDriver string
-	slaveDriver  string
-	lastInsertId int64
-	sqlLogs      []string
-	lastSql      string
+	slaveDB       *sql.DB
+	masterDB      *sql.DB
+	tx            *sql.Tx
+	masterDriver  string
+	slaveDriver   string
+	lastInsertId  int64
+	sqlLogs       []string
+	lastSql       string
+	logger        ILogger
+	slowThreshold time.Duration
+	preparedStmts *stmtCache
+	savepointSeq  int
+	cacher        Cacher
+}
+
+// EnablePrepareCache : 开启预编译语句缓存, size 为最大缓存条数, 事务内的 Prepare 不受其影响
+func (s *Session) EnablePrepareCache(size int) ISession {
+	s.preparedStmts = newStmtCache(size)
+	return s
+}
+
+// prepareCached 在未开启缓存时退化为普通 Prepare, cached 标记调用方是否需要自行 Close。
+// 它不检查 s.tx: 事务内的 Execute 由 executeContext 直接走 tx.PrepareContext, 不经过这里。
+// 查找、Prepare、写入缓存这三步通过 stmtCache.getOrPrepare 在同一个 key 上串行化, 避免两个并发
+// 请求都未命中、都各自 Prepare 出一个 *sql.Stmt, 导致后写入的那个把先写入的那个覆盖且永远不被 Close。
+func (s *Session) prepareCached(ctx context.Context, db *sql.DB, sqlstring string) (stmt *sql.Stmt, cached bool, err error) {
+	if s.preparedStmts == nil {
+		stmt, err = db.PrepareContext(ctx, sqlstring)
+		return stmt, false, err
+	}
+
+	key := stmtCacheKey(sqlstring)
+	return s.preparedStmts.getOrPrepare(key, sqlstring, func() (*sql.Stmt, error) {
+		return db.PrepareContext(ctx, sqlstring)
+	})
+}
+
+// SetLogger : 注册一个 ILogger 实现, 每次 Query/Execute 都会回调其 Log 方法
+func (s *Session) SetLogger(logger ILogger) ISession {
+	s.logger = logger
+	return s
+}
+
+// SetSlowThreshold : 设置慢查询阈值, 超过该耗时的 LogEntry.Slow 会被标记为 true
+func (s *Session) SetSlowThreshold(d time.Duration) ISession {
+	s.slowThreshold = d
+	return s
+}
+
+// log 统一记录一次 SQL 执行, start 为执行开始时间, file/line 由调用方(Query/Execute/
+// QueryContext/ExecuteContext 入口)在自己的栈帧上用 callerInfo() 采集后传入,
+// 避免在这里用一个固定的 skip 深度去猜调用链经过了几层包装。
+func (s *Session) log(sqlstring string, args []interface{}, driver string, start time.Time, rowsAffected int64, err error, file string, line int) {
+	if s.logger == nil {
+		return
+	}
+	duration := time.Since(start)
+	s.logger.Log(LogEntry{
+		SQL:          sqlstring,
+		Args:         args,
+		Driver:       driver,
+		Duration:     duration,
+		RowsAffected: rowsAffected,
+		Err:          err,
+		Slow:         s.slowThreshold > 0 && duration > s.slowThreshold,
+		File:         file,
+		Line:         line,
+	})
 }
 
 var _ ISession = &Session{}
@@ -38,6 +100,9 @@ func NewSession(e IEngin) ISession {
 
 // Close : 关闭 Session
 func (s *Session) Close() {
+	if s.preparedStmts != nil {
+		s.preparedStmts.close()
+	}
 	s.masterDB.Close()
 	s.slaveDB.Close()
 }
@@ -62,30 +127,73 @@ func (s *Session) Bind(tab interface{}) ISession {
 }
 
 func (s *Session) Begin() (err error) {
-	s.tx, err = s.masterDB.Begin()
+	return s.BeginTx(context.Background(), nil)
+}
+
+// BeginTx : 开启事务, 支持通过 ctx 取消以及自定义隔离级别/只读等选项
+func (s *Session) BeginTx(ctx context.Context, opts *sql.TxOptions) (err error) {
+	s.tx, err = s.masterDB.BeginTx(ctx, opts)
 	return
 }
 
 func (s *Session) Rollback() (err error) {
 	err = s.tx.Rollback()
 	s.tx = nil
+	s.savepointSeq = 0
 	return
 }
 
 func (s *Session) Commit() (err error) {
 	err = s.tx.Commit()
 	s.tx = nil
+	s.savepointSeq = 0
 	return
 }
 
+// Savepoint : 在当前事务内创建一个命名保存点
+func (s *Session) Savepoint(name string) error {
+	_, err := s.tx.Exec(fmt.Sprintf("SAVEPOINT %s", name))
+	return err
+}
+
+// RollbackTo : 回滚到指定保存点, 该保存点之后的变更被撤销, 但外层事务仍然存活
+func (s *Session) RollbackTo(name string) error {
+	_, err := s.tx.Exec(fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", name))
+	return err
+}
+
+// Release : 释放一个保存点, 其变更并入外层事务
+func (s *Session) Release(name string) error {
+	_, err := s.tx.Exec(fmt.Sprintf("RELEASE SAVEPOINT %s", name))
+	return err
+}
+
 func (s *Session) Transaction(closers ...func(ses ISession) error) (err error) {
-	err = s.Begin()
+	return s.TransactionContext(context.Background(), func(ctx context.Context, ses ISession) error {
+		for _, closer := range closers {
+			if err := closer(ses); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TransactionContext : 带 ctx 的事务, ctx 被取消时底层连接会中断当前事务。
+// 如果调用时已经处于事务中(例如一个仓储方法调用了另一个同样开启事务的仓储方法),
+// 不会再次 Begin 而是退化为一个 SAVEPOINT, 这样组合多个仓储方法时无需关心外层是否已有事务。
+func (s *Session) TransactionContext(ctx context.Context, closers ...func(ctx context.Context, ses ISession) error) (err error) {
+	if s.tx != nil {
+		return s.savepointTransaction(ctx, closers...)
+	}
+
+	err = s.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	for _, closer := range closers {
-		err = closer(s)
+		err = closer(ctx, s)
 		if err != nil {
 			_ = s.Rollback()
 			return
@@ -94,32 +202,92 @@ func (s *Session) Transaction(closers ...func(ses ISession) error) (err error) {
 	return s.Commit()
 }
 
+// savepointTransaction 实现嵌套事务: 用 SAVEPOINT 模拟一层可回滚但不提交连接的子事务
+func (s *Session) savepointTransaction(ctx context.Context, closers ...func(ctx context.Context, ses ISession) error) (err error) {
+	s.savepointSeq++
+	name := fmt.Sprintf("sp_%d", s.savepointSeq)
+
+	if err = s.Savepoint(name); err != nil {
+		return err
+	}
+
+	for _, closer := range closers {
+		if err = closer(ctx, s); err != nil {
+			_ = s.RollbackTo(name)
+			return err
+		}
+	}
+	return s.Release(name)
+}
+
 func (s *Session) Query(sqlstring string, args ...interface{}) error {
+	file, line := callerInfo()
+	return s.queryContext(context.Background(), file, line, sqlstring, args...)
+}
+
+// QueryContext : 同 Query, 但允许通过 ctx 取消查询或设置超时
+func (s *Session) QueryContext(ctx context.Context, sqlstring string, args ...interface{}) error {
+	file, line := callerInfo()
+	return s.queryContext(ctx, file, line, sqlstring, args...)
+}
+
+// queryContext 是 Query/QueryContext 共用的实现, file/line 由调用方在自己的栈帧上采集好再传入
+func (s *Session) queryContext(ctx context.Context, file string, line int, sqlstring string, args ...interface{}) error {
+	start := time.Now()
+
+	sqlstring, args, err := expandInArgs(s.slaveDriver, sqlstring, args)
+	if err != nil {
+		return err
+	}
+
 	s.lastSql = fmt.Sprintf(sqlstring, args...)
 	// 记录sqlLog
 	if s.IfEnableQueryLog() {
 		s.sqlLogs = append(s.sqlLogs, s.lastSql)
 	}
 
-	stmt, err := s.slaveDB.Prepare(sqlstring)
+	stmt, cached, err := s.prepareCached(ctx, s.slaveDB, sqlstring)
 	if err != nil {
+		s.log(sqlstring, args, s.slaveDriver, start, 0, err, file, line)
 		return err
 	}
+	if !cached {
+		defer stmt.Close()
+	}
 
-	defer stmt.Close()
-	rows, err := stmt.Query(args...)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
+		s.log(sqlstring, args, s.slaveDriver, start, 0, err, file, line)
 		return err
 	}
 
 	// make sure we always close rows
 	defer rows.Close()
 
-	return s.scan(rows)
+	err = s.scan(rows)
+	s.log(sqlstring, args, s.slaveDriver, start, 0, err, file, line)
+	return err
 }
 
 func (s *Session) Execute(sqlstring string, args ...interface{}) (rowsAffected int64, err error) {
-	//t_start := time.Now()
+	file, line := callerInfo()
+	return s.executeContext(context.Background(), file, line, sqlstring, args...)
+}
+
+// ExecuteContext : 同 Execute, 但允许通过 ctx 取消执行或设置超时
+func (s *Session) ExecuteContext(ctx context.Context, sqlstring string, args ...interface{}) (rowsAffected int64, err error) {
+	file, line := callerInfo()
+	return s.executeContext(ctx, file, line, sqlstring, args...)
+}
+
+// executeContext 是 Execute/ExecuteContext 共用的实现, file/line 由调用方在自己的栈帧上采集好再传入
+func (s *Session) executeContext(ctx context.Context, file string, line int, sqlstring string, args ...interface{}) (rowsAffected int64, err error) {
+	start := time.Now()
+
+	sqlstring, args, err = expandInArgs(s.masterDriver, sqlstring, args)
+	if err != nil {
+		return 0, err
+	}
 
 	s.lastSql = fmt.Sprintf(sqlstring, args...)
 	// 记录sqlLog
@@ -133,21 +301,27 @@ func (s *Session) Execute(sqlstring string, args ...interface{}) (rowsAffected i
 	}
 
 	var stmt *sql.Stmt
+	var cached bool
+	var driver = s.masterDriver
 	if s.tx == nil {
-		stmt, err = s.masterDB.Prepare(sqlstring)
+		// 预编译语句缓存只服务于非事务连接, 事务内必须使用 tx.Prepare
+		stmt, cached, err = s.prepareCached(ctx, s.masterDB, sqlstring)
 	} else {
-		stmt, err = s.tx.Prepare(sqlstring)
+		stmt, err = s.tx.PrepareContext(ctx, sqlstring)
 	}
 
 	if err != nil {
+		s.log(sqlstring, args, driver, start, 0, err, file, line)
 		return 0, err
 	}
 	//return dba.parseExecute(stmt, operType, vals)
 
-	//var err error
-	defer stmt.Close()
-	result, errs := stmt.Exec(args...)
+	if !cached {
+		defer stmt.Close()
+	}
+	result, errs := stmt.ExecContext(ctx, args...)
 	if errs != nil {
+		s.log(sqlstring, args, driver, start, 0, errs, file, line)
 		return 0, errs
 	}
 
@@ -160,6 +334,14 @@ func (s *Session) Execute(sqlstring string, args ...interface{}) (rowsAffected i
 	}
 	// get rows affected
 	rowsAffected, err = result.RowsAffected()
+	s.log(sqlstring, args, driver, start, rowsAffected, err, file, line)
+
+	// 写操作使相关表的缓存失效, 避免 Session.Cache().Query() 读到过期数据
+	if err == nil && s.cacher != nil {
+		if table := extractTableName(operType, sqlstring); table != "" {
+			s.cacher.Invalidate(table)
+		}
+	}
 
 	//// 如果是事务, 则重置所有参数
 	//if dba.Strans == true {
@@ -239,6 +421,8 @@ func (s *Session) scanMapAll(rows *sql.Rows, dst interface{}) (err error) {
 }
 
 // scan a single row of data into a struct.
+// Uses a cached per-type field plan so NULL columns, time.Time and `gorose:"json"`
+// fields are handled without panicking, instead of the raw strutForScan pointers.
 func (s *Session) scanRow(rows *sql.Rows, dst interface{}) error {
 	// check if there is data waiting
 	if !rows.Next() {
@@ -248,12 +432,7 @@ func (s *Session) scanRow(rows *sql.Rows, dst interface{}) error {
 		return sql.ErrNoRows
 	}
 
-	// get a list of targets
-	var fields = strutForScan(dst)
-
-	// perform the scan
-	if err := rows.Scan(fields...); err != nil {
-		//if err := rows.Scan(strutForScan(s.BindResult.Interface())...); err != nil {
+	if err := scanStructRow(rows, dst); err != nil {
 		return err
 	}
 
@@ -267,8 +446,7 @@ func (s *Session) scanRow(rows *sql.Rows, dst interface{}) error {
 func (s *Session) scanAll(rows *sql.Rows, dst interface{}) error {
 	for rows.Next() {
 		// scan it
-		err := rows.Scan(strutForScan(s.BindResult.Interface())...)
-		if err != nil {
+		if err := scanStructRow(rows, s.BindResult.Interface()); err != nil {
 			return err
 		}
 		// add to the result slice
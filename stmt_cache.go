@@ -0,0 +1,164 @@
+package gorose
+
+import (
+	"container/list"
+	"database/sql"
+	"hash/crc32"
+	"sync"
+)
+
+// stmtCacheEntry : LRU 链表节点携带的数据。sql 与 stmt 一起存储, 用来在 key 发生 crc32 碰撞
+// (两条不同 SQL 算出同一个 32 位 hash)时识别出来, 避免把命中的语句句柄误当成另一条 SQL 执行
+type stmtCacheEntry struct {
+	key  uint32
+	sql  string
+	stmt *sql.Stmt
+}
+
+// stmtCacheCall 代表一次正在进行中的 prepare, 用于让同一个 key 的并发请求排队等待同一次
+// Prepare 完成并复用其结果, 而不是各自发起一次 Prepare 导致多余的语句句柄泄漏
+type stmtCacheCall struct {
+	wg     sync.WaitGroup
+	sql    string
+	stmt   *sql.Stmt
+	cached bool
+	err    error
+}
+
+// stmtCache : 以 crc32(sqlstring) 为 key 的预编译语句 LRU 缓存, 淘汰时会 Close 被挤出的 *sql.Stmt
+type stmtCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[uint32]*list.Element
+	inflight map[uint32]*stmtCacheCall
+}
+
+// newStmtCache : capacity <= 0 时退化为容量 1, 避免缓存形同虚设
+func newStmtCache(capacity int) *stmtCache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &stmtCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[uint32]*list.Element, capacity),
+		inflight: make(map[uint32]*stmtCacheCall),
+	}
+}
+
+func stmtCacheKey(sqlstring string) uint32 {
+	return crc32.ChecksumIEEE([]byte(sqlstring))
+}
+
+// get : sqlstring 同时用来核对 key 对应的缓存项确实是同一条 SQL, 而不仅仅是 hash 相同
+func (c *stmtCache) get(key uint32, sqlstring string) (*sql.Stmt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getLocked(key, sqlstring)
+}
+
+func (c *stmtCache) getLocked(key uint32, sqlstring string) (*sql.Stmt, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*stmtCacheEntry)
+	if entry.sql != sqlstring {
+		// crc32 碰撞: 两条不同的 SQL 算出了同一个 key, 不能复用, 也不淘汰已有的正确条目
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.stmt, true
+}
+
+// add : 写入一条新的缓存项, 超出容量时淘汰最久未使用的 *sql.Stmt 并关闭它。
+// 返回 false 表示因为 key 碰撞而放弃缓存, 这种情况下 stmt 的所有权仍归调用方, 由调用方负责 Close。
+func (c *stmtCache) add(key uint32, sqlstring string, stmt *sql.Stmt) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addLocked(key, sqlstring, stmt)
+}
+
+func (c *stmtCache) addLocked(key uint32, sqlstring string, stmt *sql.Stmt) bool {
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*stmtCacheEntry)
+		if entry.sql == sqlstring {
+			c.ll.MoveToFront(elem)
+			entry.stmt.Close()
+			entry.stmt = stmt
+			return true
+		}
+		// 碰撞: 保留已有条目不动, 新语句句柄的所有权交还给调用方, 本次调用不走缓存
+		return false
+	}
+
+	elem := c.ll.PushFront(&stmtCacheEntry{key: key, sql: sqlstring, stmt: stmt})
+	c.items[key] = elem
+
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		entry := oldest.Value.(*stmtCacheEntry)
+		delete(c.items, entry.key)
+		entry.stmt.Close()
+	}
+	return true
+}
+
+// getOrPrepare : 在一次持有锁的 get-miss -> prepare -> insert 序列里完成查找与写入, 并发请求
+// 同一个 key 时后来者会排队等待先来者的 prepare 完成并复用其结果, 而不是各自 Prepare 一份
+// 导致前一份被覆盖却从未 Close 的语句句柄泄漏。cached 为 false 时(prepare 失败, 或者命中了
+// key 碰撞)调用方必须自行 Close 返回的 stmt; 等待中的请求只有在先来者是同一条 SQL 且已写入缓存
+// (或 prepare 失败)时才复用其结果, 否则自行 Prepare 一份不走缓存的 stmt。
+func (c *stmtCache) getOrPrepare(key uint32, sqlstring string, prepare func() (*sql.Stmt, error)) (stmt *sql.Stmt, cached bool, err error) {
+	c.mu.Lock()
+	if stmt, ok := c.getLocked(key, sqlstring); ok {
+		c.mu.Unlock()
+		return stmt, true, nil
+	}
+
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		if call.sql == sqlstring && (call.err != nil || call.cached) {
+			return call.stmt, call.cached, call.err
+		}
+		// key 碰撞, 或先来者的 stmt 没能进缓存(归先来者自己 Close): 不能共用, 单独 Prepare 一份
+		stmt, err = prepare()
+		return stmt, false, err
+	}
+
+	call := &stmtCacheCall{sql: sqlstring}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	stmt, err = prepare()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if err == nil {
+		cached = c.addLocked(key, sqlstring, stmt)
+	}
+	call.stmt, call.cached, call.err = stmt, cached, err
+	c.mu.Unlock()
+	call.wg.Done()
+
+	return stmt, cached, err
+}
+
+// close : 释放缓存中所有语句, Session.Close 时调用
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, elem := range c.items {
+		elem.Value.(*stmtCacheEntry).stmt.Close()
+	}
+	c.items = make(map[uint32]*list.Element)
+	c.ll.Init()
+}
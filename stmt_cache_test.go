@@ -0,0 +1,191 @@
+package gorose
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeStmtCacheDriver is a minimal database/sql/driver.Driver that does just enough
+// (Open/Prepare/Close) to drive *sql.DB.PrepareContext without touching a real database,
+// so prepareCached's cache-hit vs cache-disabled cost can be benchmarked in isolation.
+type fakeStmtCacheDriver struct{}
+
+type fakeStmtCacheConn struct{}
+
+type fakeStmtCacheStmt struct{}
+
+func (fakeStmtCacheDriver) Open(name string) (driver.Conn, error) {
+	return fakeStmtCacheConn{}, nil
+}
+
+func (fakeStmtCacheConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmtCacheStmt{}, nil
+}
+func (fakeStmtCacheConn) Close() error { return nil }
+func (fakeStmtCacheConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakeStmtCacheConn: transactions not supported")
+}
+
+func (fakeStmtCacheStmt) Close() error  { return nil }
+func (fakeStmtCacheStmt) NumInput() int { return -1 }
+func (fakeStmtCacheStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("fakeStmtCacheStmt: exec not supported")
+}
+func (fakeStmtCacheStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("fakeStmtCacheStmt: query not supported")
+}
+
+var registerFakeStmtCacheDriverOnce sync.Once
+
+func openFakeStmtCacheDB(tb testing.TB) *sql.DB {
+	registerFakeStmtCacheDriverOnce.Do(func() {
+		sql.Register("gorose_fake_stmt_cache", fakeStmtCacheDriver{})
+	})
+	db, err := sql.Open("gorose_fake_stmt_cache", "")
+	if err != nil {
+		tb.Fatalf("failed to open fake db: %v", err)
+	}
+	return db
+}
+
+// BenchmarkStmtCache_Hit : 缓存命中时的 get 开销, 对应复用同一条预编译语句的热路径
+func BenchmarkStmtCache_Hit(b *testing.B) {
+	c := newStmtCache(32)
+	keys := make([]uint32, 32)
+	sqls := make([]string, 32)
+	for i := range keys {
+		sqls[i] = strconv.Itoa(i)
+		keys[i] = stmtCacheKey(sqls[i])
+		c.add(keys[i], sqls[i], &sql.Stmt{})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx := i % len(keys)
+		c.get(keys[idx], sqls[idx])
+	}
+}
+
+// BenchmarkStmtCache_Miss : 每次都是新 SQL 的插入开销, 对应无复用场景下的基线
+func BenchmarkStmtCache_Miss(b *testing.B) {
+	// 容量覆盖整个 b.N, 避免触发淘汰时对占位 *sql.Stmt 调用 Close
+	c := newStmtCache(b.N + 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sqlstring := strconv.Itoa(i)
+		c.add(stmtCacheKey(sqlstring), sqlstring, &sql.Stmt{})
+	}
+}
+
+// TestStmtCache_CollisionKeepsOriginalEntry : 两条不同 SQL 碰巧算出同一个 key 时, 已有条目不应
+// 被错误地换成另一条 SQL 的语句句柄
+func TestStmtCache_CollisionKeepsOriginalEntry(t *testing.T) {
+	c := newStmtCache(8)
+	const key = uint32(1)
+	first, second := &sql.Stmt{}, &sql.Stmt{}
+
+	if ok := c.add(key, "SELECT 1", first); !ok {
+		t.Fatalf("expected first insert to succeed")
+	}
+	if ok := c.add(key, "SELECT 2", second); ok {
+		t.Fatalf("expected colliding insert to be rejected")
+	}
+
+	got, ok := c.get(key, "SELECT 1")
+	if !ok || got != first {
+		t.Fatalf("expected original entry for SELECT 1 to survive the collision")
+	}
+
+	if _, ok := c.get(key, "SELECT 2"); ok {
+		t.Fatalf("expected no cached entry for the colliding SQL")
+	}
+}
+
+// TestStmtCache_GetOrPrepare_DedupesConcurrentMisses : 并发请求同一个未命中的 key 时只应该
+// 实际 prepare 一次, 其余请求复用同一个结果, 不会出现互相覆盖导致的语句句柄泄漏
+func TestStmtCache_GetOrPrepare_DedupesConcurrentMisses(t *testing.T) {
+	c := newStmtCache(8)
+
+	var prepareCount int32
+	var mu sync.Mutex
+	prepare := func() (*sql.Stmt, error) {
+		mu.Lock()
+		prepareCount++
+		mu.Unlock()
+		return &sql.Stmt{}, nil
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]*sql.Stmt, n)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			stmt, _, err := c.getOrPrepare(42, "SELECT * FROM t", prepare)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			results[i] = stmt
+		}()
+	}
+	wg.Wait()
+
+	if prepareCount != 1 {
+		t.Fatalf("expected exactly 1 prepare call, got %d", prepareCount)
+	}
+	for i, stmt := range results {
+		if stmt != results[0] {
+			t.Fatalf("result %d got a different *sql.Stmt than result 0", i)
+		}
+	}
+}
+
+// BenchmarkPrepareCached_CacheEnabled : 重复执行同一条 SQL, 开启缓存后除了第一次都直接命中,
+// 不再走 db.PrepareContext 这一跳, 对应这个功能真正要交付的"重复查询免重复预编译"收益
+func BenchmarkPrepareCached_CacheEnabled(b *testing.B) {
+	db := openFakeStmtCacheDB(b)
+	defer db.Close()
+
+	s := &Session{}
+	s.EnablePrepareCache(8)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt, cached, err := s.prepareCached(ctx, db, "SELECT 1")
+		if err != nil {
+			b.Fatalf("prepareCached failed: %v", err)
+		}
+		if !cached {
+			stmt.Close()
+		}
+	}
+}
+
+// BenchmarkPrepareCached_CacheDisabled : 未开启缓存时的基线, 每次都要重新 Prepare 再 Close,
+// 和 BenchmarkPrepareCached_CacheEnabled 对比才能看出缓存到底省了什么
+func BenchmarkPrepareCached_CacheDisabled(b *testing.B) {
+	db := openFakeStmtCacheDB(b)
+	defer db.Close()
+
+	s := &Session{}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt, _, err := s.prepareCached(ctx, db, "SELECT 1")
+		if err != nil {
+			b.Fatalf("prepareCached failed: %v", err)
+		}
+		stmt.Close()
+	}
+}